@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// gotestEvent is one newline-delimited JSON event from `go test -json`, as
+// documented at https://pkg.go.dev/cmd/test2json. Only the fields the
+// gotest adapter cares about are decoded; Output (the "run"/"output"
+// events' captured test output) isn't used.
+type gotestEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+}
+
+// openTAPInput returns the stream runStream/runEmit should actually parse
+// as TAP: fh unchanged, unless opts.From (or auto-detection, when
+// opts.From is "auto") says the input is a `go test -json` event stream,
+// in which case it's converted to an equivalent TAP 13 stream first.
+func openTAPInput(opts options, fh io.Reader) (io.Reader, error) {
+	from := opts.From
+	// Sized to looksLikeGotestJSON's 1MB peek limit, since Peek can't
+	// return more than the reader's buffer holds.
+	br := bufio.NewReaderSize(fh, 1<<20)
+	if from == "" || from == "auto" {
+		if looksLikeGotestJSON(br) {
+			from = "gotest"
+		} else {
+			from = "tap"
+		}
+	}
+	if from != "gotest" {
+		return br, nil
+	}
+	return convertGotestJSON(br)
+}
+
+// looksLikeGotestJSON reports whether br's first line looks like a `go
+// test -json` event: a JSON object with an "Action" field. It peeks at
+// br without consuming it, so br can still be read from normally
+// afterwards regardless of the result.
+func looksLikeGotestJSON(br *bufio.Reader) bool {
+	// Grow the peek until the first line is fully buffered, so a long
+	// package/test name doesn't truncate it mid-object.
+	var peek []byte
+	for size := 4096; ; size *= 2 {
+		var err error
+		peek, err = br.Peek(size)
+		if bytes.IndexByte(peek, '\n') >= 0 || err != nil || size >= 1<<20 {
+			break
+		}
+	}
+	if len(peek) == 0 {
+		return false
+	}
+	line := peek
+	if idx := bytes.IndexByte(peek, '\n'); idx >= 0 {
+		line = peek[:idx]
+	}
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return false
+	}
+	var ev map[string]interface{}
+	if err := json.Unmarshal(line, &ev); err != nil {
+		return false
+	}
+	_, ok := ev["Action"]
+	return ok
+}
+
+// gotestNode accumulates the `go test -json` events seen for a single
+// test (or, at the root, a single package), including any t.Run subtests
+// nested under it, keyed by their "/"-separated path component.
+type gotestNode struct {
+	action     string // "pass", "fail" or "skip", once known
+	elapsed    float64
+	elapsedSet bool
+	children   []string
+	childNodes map[string]*gotestNode
+}
+
+func newGotestNode() *gotestNode {
+	return &gotestNode{childNodes: make(map[string]*gotestNode)}
+}
+
+func (n *gotestNode) child(name string) *gotestNode {
+	c, ok := n.childNodes[name]
+	if !ok {
+		c = newGotestNode()
+		n.childNodes[name] = c
+		n.children = append(n.children, name)
+	}
+	return c
+}
+
+// convertGotestJSON reads a `go test -json` event stream and synthesizes
+// an equivalent TAP 13 stream from it: one top-level test per
+// package+test, named "Package.Test"; t.Run subtests as nested
+// "# Subtest:" streams (arbitrarily deep); a SKIP directive for skipped
+// tests; and a YAML diagnostic block carrying data.duration_ms under any
+// test go test reported an Elapsed time for.
+func convertGotestJSON(r io.Reader) (io.Reader, error) {
+	var packages []string
+	roots := make(map[string]*gotestNode)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var ev gotestEvent
+		if err := json.Unmarshal([]byte(text), &ev); err != nil {
+			return nil, fmt.Errorf("decoding go test -json event: %w", err)
+		}
+		if ev.Test == "" {
+			// A package-level event (build failure, package summary etc),
+			// not a test; the gotest adapter only synthesizes TAP tests.
+			continue
+		}
+		root, ok := roots[ev.Package]
+		if !ok {
+			root = newGotestNode()
+			roots[ev.Package] = root
+			packages = append(packages, ev.Package)
+		}
+		node := root
+		for _, part := range strings.Split(ev.Test, "/") {
+			node = node.child(part)
+		}
+		switch ev.Action {
+		case "pass", "fail", "skip":
+			node.action = ev.Action
+			node.elapsed = ev.Elapsed
+			node.elapsedSet = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, pkg := range packages {
+		total += len(roots[pkg].children)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("TAP version 13\n")
+	fmt.Fprintf(&sb, "1..%d\n", total)
+	num := 0
+	for _, pkg := range packages {
+		root := roots[pkg]
+		for _, name := range root.children {
+			num++
+			writeGotestNode(&sb, "", root.childNodes[name], num, pkg+"."+name)
+		}
+	}
+	return strings.NewReader(sb.String()), nil
+}
+
+// writeGotestNode renders node (and any subtests nested under it) as TAP,
+// at the given indent, with num as its test number in its enclosing
+// stream and displayName as its "ok/not ok" description.
+func writeGotestNode(sb *strings.Builder, indent string, node *gotestNode, num int, displayName string) {
+	if len(node.children) > 0 {
+		// The subtest marker name must match displayName exactly, since
+		// that's what this node's own ok/not-ok summary line (printed
+		// below) will be validated against once its inner plan closes.
+		fmt.Fprintf(sb, "%s# Subtest: %s\n", indent, displayName)
+		fmt.Fprintf(sb, "%s    1..%d\n", indent, len(node.children))
+		for i, name := range node.children {
+			writeGotestNode(sb, indent+"    ", node.childNodes[name], i+1, name)
+		}
+	}
+
+	// A test with no recorded pass/fail/skip action never finished (its
+	// test binary crashed, panicked or was killed before reporting a
+	// result) and is treated as failed rather than silently passing.
+	status := "not ok"
+	if node.action == "pass" || node.action == "skip" {
+		status = "ok"
+	}
+	fmt.Fprintf(sb, "%s%s %d - %s", indent, status, num, displayName)
+	if node.action == "skip" {
+		sb.WriteString(" # SKIP")
+	}
+	sb.WriteString("\n")
+	if node.elapsedSet {
+		// A real YAML diagnostic block, not a bare comment, so
+		// duration_ms round-trips through tap.ParseYAMLDiag (and from
+		// there into --junit's time="" attribute) like any other TAP
+		// producer's diagnostics would.
+		fmt.Fprintf(sb, "%s---\n", indent)
+		fmt.Fprintf(sb, "%sdata:\n", indent)
+		fmt.Fprintf(sb, "%s  duration_ms: %d\n", indent, int(node.elapsed*1000+0.5))
+		fmt.Fprintf(sb, "%s...\n", indent)
+	}
+}
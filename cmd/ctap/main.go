@@ -0,0 +1,1474 @@
+/*
+ctap is a lightweight, portable colouriser for TAP
+(Test-Anything-Protocol) output
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/gavincarr/ctap/render"
+	"github.com/gavincarr/ctap/tap"
+)
+
+const (
+	testFailExitCode = 3
+	planFailExitCode = 4
+	bailExitCode     = 5
+
+	defaultGlob = "*.tap"
+
+	// Usage addendum
+	usageAddendum = `
+Colour strings may be any of the following colour names:
+
+  red, green, blue, yellow, cyan, magenta, white, black, gray, default
+
+They may also be hex colour strings like "#cc9900" or "#c90" (with the
+leading "#" optional).
+
+Colour names or hex strings can also have any of the following modifiers
+appended to them (space-separated):
+
+  bold, italic, underscore, reverse, blink, concealed, fuzzy
+
+(though how they work will depend on your terminal support)
+`
+)
+
+type options struct {
+	Failures bool   `short:"f" long:"failures" description:"show test failures (suppress TAP successes)"`
+	Glyphs   bool   `short:"g" long:"glyphs" description:"show \u2713\u2717 glyphs instead of 'ok/not ok' in TAP output"`
+	Summary  bool   `short:"s" long:"summary" description:"append a Test::Harness-like summary of the test results"`
+	CVersion string `short:"V" long:"cversion" description:"colour to use for version lines"`
+	CPlan    string `short:"P" long:"cplan" description:"colour to use for plan lines"`
+	COk      string `short:"O" long:"cok" description:"colour to use for test ok lines"`
+	CFail    string `short:"F" long:"cfail" description:"colour to use for test fail/not ok lines"`
+	CDiag    string `short:"D" long:"cdiag" description:"colour to use for diagnostic lines"`
+	CBail    string `short:"B" long:"cbail" description:"colour to use for bail out lines"`
+	CYAML    string `long:"cyaml" description:"colour to use for YAML diagnostic blocks"`
+	NoYAML   bool   `long:"no-yaml" description:"don't decode YAML diagnostic blocks, pass them through as raw, uncoloured lines"`
+	CSkip    string `long:"cskip" description:"colour to use for SKIP directive tests"`
+	CTodo    string `long:"ctodo" description:"colour to use for TODO directive tests"`
+	Recurse  bool   `short:"r" long:"recurse" description:"treat file arguments as directories and recurse into them, collecting files matching --glob"`
+	Glob     string `long:"glob" description:"glob pattern used to find TAP files when recursing (default \"*.tap\")"`
+	// tapj (TAP-J streamed JSON events) is intentionally not one of the
+	// choices below: unlike json's one-object-per-test shape, TAP-J needs
+	// its own begin/stdout-line/complete event schema rather than another
+	// Emitter on the existing testRecord model, so it's out of scope here.
+	Format      string `short:"o" long:"format" choice:"tty" choice:"junit" choice:"json" default:"tty" description:"output format: tty (coloured TAP, default), junit (JUnit XML) or json (one JSON object per test)"`
+	Interactive bool   `short:"i" long:"interactive" description:"on failure, pause and prompt to continue, skip the rest of the file, quit, show a diff, or open $EDITOR (requires a TTY)"`
+	Theme       string `long:"theme" description:"colour theme to use (see --list-themes); CLI colour flags override theme values"`
+	ListThemes  bool   `long:"list-themes" description:"list available colour themes and exit"`
+	Junit       string `short:"j" long:"junit" description:"write a JUnit XML report to FILE, alongside the normal coloured output"`
+	From        string `long:"from" choice:"auto" choice:"tap" choice:"gotest" default:"auto" description:"input format: auto (detect TAP or 'go test -json', default), tap, or gotest"`
+	Args        struct {
+		TapFiles []string `positional-arg-name:"tap-file"`
+	} `positional-args:"yes"`
+}
+
+var opts options
+
+// subtestFrame tracks TAP plan/test state for the root stream, or for one
+// level of a TAP 14 nested subtest stream introduced by "# Subtest: name".
+// Nested streams are indented 4 spaces relative to their parent.
+type subtestFrame struct {
+	name       string
+	indent     string
+	planSeen   bool
+	planLast   int
+	testnum    int
+	failures   []int
+	skipped    int
+	todoCount  int
+	todoPassed int
+	// pendingChild is set once a nested subtest's own plan has been
+	// satisfied, so the next ok/not-ok line in this frame (its summary
+	// line) can be validated against what the child actually reported.
+	pendingChild *subtestFrame
+}
+
+// subtestResult is the final pass/fail tally for a completed subtest,
+// used to render a per-subtest breakdown in the summary. depth is the
+// subtest's nesting level (1 for a top-level "# Subtest:", 2 for a
+// subtest of a subtest, etc), used to indent a tree-shaped --summary.
+type subtestResult struct {
+	name       string
+	testnum    int
+	failures   int
+	skipped    int
+	todoCount  int
+	todoPassed int
+	depth      int
+}
+
+// triageAction is the outcome of an interactive failure-triage prompt.
+type triageAction int
+
+const (
+	triageContinue triageAction = iota
+	triageSkipFile
+	triageQuit
+)
+
+// openTTY opens the controlling terminal directly, so --interactive can
+// still read keypresses when TAP input is itself being read from a piped
+// os.Stdin.
+func openTTY() (*os.File, error) {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CON"
+	}
+	return os.Open(name)
+}
+
+// interactiveReader returns the input to read triage keypresses from, and
+// whether interactive mode is actually usable given the current
+// environment. Interactive mode requires a TTY on stdout (so the prompt
+// and any diff are actually visible); the prompt input itself comes from
+// stdin when TAP is read from file arguments and stdin is a TTY, or
+// otherwise from the controlling terminal directly, since stdin is then
+// carrying the TAP data.
+func interactiveReader(opts options) (*os.File, bool) {
+	if !opts.Interactive {
+		return nil, false
+	}
+	if !color.IsTerminal(os.Stdout.Fd()) {
+		return nil, false
+	}
+	if len(opts.Args.TapFiles) > 0 && color.IsTerminal(os.Stdin.Fd()) {
+		return os.Stdin, true
+	}
+	tty, err := openTTY()
+	if err != nil {
+		return nil, false
+	}
+	return tty, true
+}
+
+// promptTriage prompts the user for what to do about a failing test,
+// looping on [d]iff and [o]pen-editor (which re-prompt) until one of
+// [c]ontinue, [s]kip or [q]uit is chosen. ttyFile is also used as the
+// editor's stdin, since os.Stdin may be busy carrying piped TAP data.
+func promptTriage(in *bufio.Reader, ttyFile *os.File, out io.Writer, diag *tap.YAMLDiag, cmap render.ColourMap) triageAction {
+	for {
+		fmt.Fprint(out, "[c]ontinue, [s]kip rest of file, [q]uit, [d]iff, [o]pen editor? ")
+		resp, err := in.ReadString('\n')
+		if err != nil {
+			return triageQuit
+		}
+		switch strings.ToLower(strings.TrimSpace(resp)) {
+		case "", "c":
+			return triageContinue
+		case "s":
+			return triageSkipFile
+		case "q":
+			return triageQuit
+		case "d":
+			printTriageDiff(out, diag, cmap)
+		case "o":
+			openEditorAt(ttyFile, out, diag)
+		default:
+			fmt.Fprintln(out, "unrecognised choice")
+		}
+	}
+}
+
+// unifiedDiffLines renders expected vs got as unified diff lines (no
+// "--- a/+++ b" file headers, since there are no real files involved),
+// for embedding under a YAML diagnostic block or an interactive triage
+// [d]iff prompt.
+func unifiedDiffLines(expected, got string) []string {
+	ud := difflib.UnifiedDiff{
+		A:       difflib.SplitLines(expected),
+		B:       difflib.SplitLines(got),
+		Context: 3,
+	}
+	text, err := difflib.GetUnifiedDiffString(ud)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(text, "\n"), "\n")
+}
+
+// printTriageDiff shows the got/expected diff for a failing test's YAML
+// diagnostic block, reusing the same unified diff rendering as the
+// coloured YAML block output.
+func printTriageDiff(out io.Writer, diag *tap.YAMLDiag, cmap render.ColourMap) {
+	if diag == nil {
+		fmt.Fprintln(out, "no diagnostics available for this test")
+		return
+	}
+	got, gotOK := diag.Got.(string)
+	expected, expectedOK := diag.Expected.(string)
+	if !gotOK || !expectedOK {
+		fmt.Fprintln(out, "no got/expected diff available for this test")
+		return
+	}
+	for _, dl := range unifiedDiffLines(expected, got) {
+		switch {
+		case strings.HasPrefix(dl, "+"):
+			cmap[tap.TestOK].Println(dl)
+		case strings.HasPrefix(dl, "-"):
+			cmap[tap.TestNOK].Println(dl)
+		default:
+			cmap[tap.YAMLBlock].Println(dl)
+		}
+	}
+}
+
+// openEditorAt opens $EDITOR at the file:line recorded in a failing
+// test's YAML "at" key, if any.
+func openEditorAt(ttyFile *os.File, out io.Writer, diag *tap.YAMLDiag) {
+	if diag == nil || diag.At == nil || diag.At.File == "" {
+		fmt.Fprintln(out, "no file:line available for this test")
+		return
+	}
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		fmt.Fprintln(out, "$EDITOR is not set")
+		return
+	}
+	args := []string{diag.At.File}
+	if diag.At.Line > 0 {
+		args = []string{fmt.Sprintf("+%d", diag.At.Line), diag.At.File}
+	}
+	cmd := exec.Command(editor, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = ttyFile, out, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(out, "failed to run $EDITOR: %s\n", err)
+	}
+}
+
+// themeConfig is a named set of colour strings covering the same lines
+// as the CLI's --cversion/--cplan/--cok/--cfail/--cdiag/--cbail/--cyaml/
+// --cskip/--ctodo flags, loaded either from a builtin theme or the
+// user's config file.
+type themeConfig struct {
+	Version string `yaml:"version"`
+	Plan    string `yaml:"plan"`
+	Ok      string `yaml:"ok"`
+	Fail    string `yaml:"fail"`
+	Diag    string `yaml:"diag"`
+	Bail    string `yaml:"bail"`
+	Yaml    string `yaml:"yaml"`
+	Skip    string `yaml:"skip"`
+	Todo    string `yaml:"todo"`
+}
+
+// userConfig is the decoded form of $XDG_CONFIG_HOME/ctap/config.yaml (or
+// the platform equivalent returned by os.UserConfigDir).
+type userConfig struct {
+	Themes map[string]themeConfig `yaml:"themes"`
+}
+
+const (
+	configDirName  = "ctap"
+	configFileName = "config.yaml"
+)
+
+// builtinThemes ship with ctap so --theme works with no config file at
+// all; a user config file may add further themes, or override these by
+// reusing the same name.
+var builtinThemes = map[string]themeConfig{
+	"dark": {
+		Version: "cyan",
+		Plan:    "white",
+		Ok:      "green",
+		Fail:    "red bold",
+		Diag:    "gray",
+		Bail:    "yellow bold",
+		Yaml:    "gray",
+		Skip:    "yellow",
+		Todo:    "blue",
+	},
+	"light": {
+		Version: "blue",
+		Plan:    "black",
+		Ok:      "green",
+		Fail:    "red bold",
+		Diag:    "black",
+		Bail:    "magenta bold",
+		Yaml:    "black",
+		Skip:    "yellow",
+		Todo:    "blue",
+	},
+	"solarized": {
+		Version: "#268bd2",
+		Plan:    "#93a1a1",
+		Ok:      "#859900",
+		Fail:    "#dc322f bold",
+		Diag:    "#586e75",
+		Bail:    "#b58900 bold",
+		Yaml:    "#586e75",
+		Skip:    "#b58900",
+		Todo:    "#2aa198",
+	},
+}
+
+// userConfigPath returns the path ctap reads its config file from:
+// $XDG_CONFIG_HOME/ctap/config.yaml, or the platform equivalent returned
+// by os.UserConfigDir (e.g. %AppData%\ctap\config.yaml on Windows).
+func userConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configDirName, configFileName), nil
+}
+
+// loadUserConfig reads and decodes the config file at path. A missing
+// file is not an error; it is treated the same as an empty config.
+func loadUserConfig(path string) (userConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return userConfig{}, nil
+	}
+	if err != nil {
+		return userConfig{}, err
+	}
+	var cfg userConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return userConfig{}, err
+	}
+	return cfg, nil
+}
+
+// lookupTheme finds a theme by name, preferring one defined in cfg over
+// a builtin theme of the same name.
+func lookupTheme(cfg userConfig, name string) (themeConfig, bool) {
+	if t, ok := cfg.Themes[name]; ok {
+		return t, true
+	}
+	t, ok := builtinThemes[name]
+	return t, ok
+}
+
+// themeNames lists all themes available from cfg and the builtin set,
+// sorted for stable --list-themes output.
+func themeNames(cfg userConfig) []string {
+	names := make(map[string]bool)
+	for name := range builtinThemes {
+		names[name] = true
+	}
+	for name := range cfg.Themes {
+		names[name] = true
+	}
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// applyTheme fills in any of opt's colour flags that weren't already set
+// on the command line, so CLI flags always take precedence over a theme.
+func applyTheme(opt *options, t themeConfig) {
+	if opt.CVersion == "" {
+		opt.CVersion = t.Version
+	}
+	if opt.CPlan == "" {
+		opt.CPlan = t.Plan
+	}
+	if opt.COk == "" {
+		opt.COk = t.Ok
+	}
+	if opt.CFail == "" {
+		opt.CFail = t.Fail
+	}
+	if opt.CDiag == "" {
+		opt.CDiag = t.Diag
+	}
+	if opt.CBail == "" {
+		opt.CBail = t.Bail
+	}
+	if opt.CYAML == "" {
+		opt.CYAML = t.Yaml
+	}
+	if opt.CSkip == "" {
+		opt.CSkip = t.Skip
+	}
+	if opt.CTodo == "" {
+		opt.CTodo = t.Todo
+	}
+}
+
+func getColourMap(opt options) render.ColourMap {
+	theme := render.Theme{
+		Version: opt.CVersion,
+		Plan:    opt.CPlan,
+		OK:      opt.COk,
+		Fail:    opt.CFail,
+		Diag:    opt.CDiag,
+		Bail:    opt.CBail,
+		Skip:    opt.CSkip,
+		Todo:    opt.CTodo,
+		YAML:    opt.CYAML,
+	}
+	cmap, err := render.NewColourMap(theme)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cmap
+}
+
+func failureString(failures []int) string {
+	var sb strings.Builder
+	for i, n := range failures {
+		if i == 0 {
+			fmt.Fprintf(&sb, "%d", n)
+		} else {
+			fmt.Fprintf(&sb, ", %d", n)
+		}
+	}
+	return sb.String()
+}
+
+// printYAMLDiag renders a decoded YAML diagnostic block, colouring keys
+// with the tap.YAMLBlock colour, got/expected (when both are strings) as a
+// unified diff, and highlighting any stack trace.
+func printYAMLDiag(diag tap.YAMLDiag, cmap render.ColourMap, opts options) {
+	field := func(key string, val interface{}) {
+		if val == nil {
+			return
+		}
+		cmap[tap.YAMLBlock].Printf("    %s: %v\n", key, val)
+	}
+
+	if diag.Message != "" {
+		field("message", diag.Message)
+	}
+	if diag.Severity != "" {
+		field("severity", diag.Severity)
+	}
+	field("data", diag.Data)
+
+	got, gotOK := diag.Got.(string)
+	expected, expectedOK := diag.Expected.(string)
+	if gotOK && expectedOK {
+		cmap[tap.YAMLBlock].Println("    diff:")
+		for _, dl := range unifiedDiffLines(expected, got) {
+			switch {
+			case strings.HasPrefix(dl, "+"):
+				cmap[tap.TestOK].Println("      " + dl)
+			case strings.HasPrefix(dl, "-"):
+				cmap[tap.TestNOK].Println("      " + dl)
+			default:
+				cmap[tap.YAMLBlock].Println("      " + dl)
+			}
+		}
+	} else {
+		field("got", diag.Got)
+		field("expected", diag.Expected)
+	}
+
+	if diag.At != nil {
+		cmap[tap.YAMLBlock].Printf("    at: %s:%d\n", diag.At.File, diag.At.Line)
+	}
+	if diag.Stack != "" {
+		stackColour, err := render.ParseColour(render.DefaultTheme().Stack)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stackColour.Printf("    stack: %s\n", diag.Stack)
+	}
+}
+
+func printSummary(failures []int, testnum int, planNOK bool, skipped, todoCount, todoPassed int,
+	subtests []subtestResult, cmap render.ColourMap, opts options) {
+	plural := ""
+	glyph := ""
+
+	if len(failures) > 0 {
+		if len(failures) > 1 {
+			plural = "s"
+		}
+		if opts.Glyphs {
+			glyph = render.GlyphNOK + " "
+		}
+		cmap[tap.SummaryNOK].Printf("%sFAILED test%s: %s\n",
+			glyph, plural,
+			failureString(failures))
+		cmap[tap.SummaryNOK].Printf("%sFailed %d/%d tests, %0.02f%% ok\n",
+			glyph, len(failures), testnum,
+			float64(testnum-len(failures))*100/float64(testnum))
+	} else if !planNOK {
+		if opts.Glyphs {
+			glyph = render.GlyphOK + " "
+		}
+		cmap[tap.SummaryOK].Printf("%sPassed %d/%d tests, 100%% ok\n",
+			glyph, testnum, testnum)
+	}
+
+	if skipped > 0 || todoCount > 0 {
+		cmap[tap.TestSkip].Printf("Skipped %d, TODO %d (unexpectedly passed: %d)\n",
+			skipped, todoCount, todoPassed)
+	}
+
+	for _, s := range subtests {
+		status := cmap[tap.SummaryOK]
+		if s.failures > 0 {
+			status = cmap[tap.SummaryNOK]
+		}
+		indent := strings.Repeat("  ", s.depth)
+		status.Printf("%sSubtest %s: Failed %d/%d\n", indent, s.name, s.failures, s.testnum)
+	}
+}
+
+func printAppends(failures []int, testnum, planLast, exitCode int, skipped, todoCount, todoPassed int,
+	subtests []subtestResult, cmap render.ColourMap, opts options) int {
+	planNOK := testnum == 0 || testnum != planLast
+	if planNOK && exitCode < planFailExitCode {
+		exitCode = planFailExitCode
+	}
+
+	if opts.Summary {
+		printSummary(failures, testnum, planNOK, skipped, todoCount, todoPassed, subtests, cmap, opts)
+	}
+
+	// Fail if we haven't seen all planned tests
+	if planNOK {
+		glyph := ""
+		if opts.Glyphs {
+			glyph = render.GlyphNOK + " "
+		}
+		if testnum == 0 {
+			cmap[tap.PlanNOK].Printf("%sFailed plan: no tests seen\n", glyph)
+		} else {
+			cmap[tap.PlanNOK].Printf("%sFailed plan: only %d/%d planned tests seen\n",
+				glyph, testnum, planLast)
+		}
+	}
+
+	return exitCode
+}
+
+// runResult is the tally for a single TAP stream, used both to report a
+// single file's exit code and to build an aggregate summary over several.
+type runResult struct {
+	exitCode int
+	testnum  int
+	failed   int
+	quit     bool // true if --interactive triage chose [q]uit
+}
+
+// runCLI runs a single TAP stream (opts.Args.TapFiles[0], or stdin if
+// empty) and returns its exit code.
+func runCLI(opts options, ofh io.Writer) (int, error) {
+	var rep reporter
+	if opts.Junit != "" {
+		rep = newJunitReporter(reportName(opts.Args.TapFiles))
+	}
+	res, err := runStream(opts, ofh, rep)
+	if err != nil {
+		return 0, err
+	}
+	if rep != nil {
+		if err := writeJunitReport(opts.Junit, []reporter{rep}); err != nil {
+			return 0, err
+		}
+	}
+	return res.exitCode, nil
+}
+
+// runStream runs a single TAP stream, colourising it to ofh. If rep is
+// non-nil, every root-level test (and the raw TAP text) is also fed to it,
+// for the caller to write out as a side-channel report (e.g. --junit) once
+// the stream has been fully consumed.
+func runStream(opts options, ofh io.Writer, rep reporter) (runResult, error) {
+	// Setup
+	log.SetFlags(0)
+	var fh *os.File
+	var err error
+	if len(opts.Args.TapFiles) > 0 {
+		fh, err = os.Open(opts.Args.TapFiles[0])
+		if err != nil {
+			return runResult{}, err
+		}
+		defer fh.Close()
+	} else {
+		fh = os.Stdin
+	}
+	input, err := openTAPInput(opts, fh)
+	if err != nil {
+		return runResult{}, err
+	}
+	scanner := bufio.NewScanner(input)
+
+	// Setup colours
+	color.SetOutput(ofh)
+	// Force colours in CI environments
+	if _, ok := os.LookupEnv("CI"); ok {
+		color.ForceOpenColor()
+	}
+	cmap := getColourMap(opts)
+
+	// Set up --interactive triage, if usable in this environment
+	var triageIn *bufio.Reader
+	var ttyFile *os.File
+	if tty, ok := interactiveReader(opts); ok {
+		ttyFile = tty
+		triageIn = bufio.NewReader(tty)
+		if tty != os.Stdin {
+			defer tty.Close()
+		}
+	}
+	pendingTriage := false
+	var pendingDiag *tap.YAMLDiag
+
+	// Process input. The subtest stack always has the root stream as its
+	// first entry; nested "# Subtest:" streams push additional frames.
+	root := &subtestFrame{}
+	stack := []*subtestFrame{root}
+	var subtestResults []subtestResult
+	exitCode := 0
+	quit := false
+
+	// YAML diagnostic block state, buffered after a preceding test line
+	inYAMLBlock := false
+	var yamlLines []string
+	lastTestType := tap.Unknown
+
+	// Root-level test currently being accumulated for rep, if any; flushed
+	// whenever the next test starts or the stream ends.
+	var pendingRec *testRecord
+	flushPendingRec := func() {
+		if rep != nil && pendingRec != nil {
+			rep.addTest(*pendingRec)
+			pendingRec = nil
+		}
+	}
+
+	for scanner.Scan() {
+		text := scanner.Text()
+		if rep != nil {
+			rep.addRaw(text)
+		}
+
+		// Pop a subtest once its inner plan has been satisfied, but only
+		// once we're past its own trailing diagnostics/YAML block: those
+		// still carry its indent, and popping before consuming them would
+		// misattribute them to the parent frame (or root).
+		for len(stack) > 1 {
+			top := stack[len(stack)-1]
+			if !top.planSeen || top.testnum < top.planLast || strings.HasPrefix(text, top.indent) {
+				break
+			}
+			finished := top
+			poppedDepth := len(stack) - 1
+			stack = stack[:len(stack)-1]
+			subtestResults = append(subtestResults, subtestResult{
+				name:       finished.name,
+				testnum:    finished.testnum,
+				failures:   len(finished.failures),
+				skipped:    finished.skipped,
+				todoCount:  finished.todoCount,
+				todoPassed: finished.todoPassed,
+				depth:      poppedDepth,
+			})
+			stack[len(stack)-1].pendingChild = finished
+		}
+
+		cur := stack[len(stack)-1]
+
+		// Dedent relative to the current frame's nesting level
+		dedented := text
+		if cur.indent != "" && strings.HasPrefix(text, cur.indent) {
+			dedented = text[len(cur.indent):]
+		}
+		depth := len(stack) - 1
+		padding := strings.Repeat("  ", depth)
+
+		if inYAMLBlock {
+			if tap.ReYAMLEnd.MatchString(dedented) {
+				inYAMLBlock = false
+				if !(opts.Failures && (lastTestType == tap.TestOK || lastTestType == tap.TestSkip)) {
+					if diag, err := tap.ParseYAMLDiag(yamlLines); err == nil {
+						printYAMLDiag(diag, cmap, opts)
+						if pendingTriage {
+							pendingDiag = &diag
+						}
+						if pendingRec != nil && depth == 0 {
+							pendingRec.YAML = &diag
+						}
+					}
+				}
+				if pendingTriage {
+					switch promptTriage(triageIn, ttyFile, ofh, pendingDiag, cmap) {
+					case triageSkipFile:
+						pendingTriage = false
+						goto doneScanning
+					case triageQuit:
+						pendingTriage, quit = false, true
+						goto doneScanning
+					}
+					pendingTriage, pendingDiag = false, nil
+				}
+				continue
+			}
+			yamlLines = append(yamlLines, dedented)
+			continue
+		}
+
+		line := tap.ParseLine(dedented)
+
+		enteringYAML := !opts.NoYAML && line.Type == tap.Unknown && tap.ReYAMLStart.MatchString(dedented) &&
+			(lastTestType == tap.TestOK || lastTestType == tap.TestNOK ||
+				lastTestType == tap.TestSkip || lastTestType == tap.TestTodo)
+
+		if pendingTriage && !enteringYAML {
+			switch promptTriage(triageIn, ttyFile, ofh, pendingDiag, cmap) {
+			case triageSkipFile:
+				pendingTriage = false
+				goto doneScanning
+			case triageQuit:
+				pendingTriage, quit = false, true
+				goto doneScanning
+			}
+			pendingTriage, pendingDiag = false, nil
+		}
+
+		if enteringYAML {
+			inYAMLBlock = true
+			yamlLines = nil
+			continue
+		}
+
+		if m := tap.ReSubtest.FindStringSubmatch(dedented); m != nil {
+			render.Cprintln(cmap, padding+dedented, tap.Diagnostic, opts.Failures, opts.Glyphs)
+			stack = append(stack, &subtestFrame{name: m[1], indent: cur.indent + "    "})
+			continue
+		}
+
+		render.Cprintln(cmap, padding+dedented, line.Type, opts.Failures, opts.Glyphs)
+
+		switch line.Type {
+		case tap.Plan:
+			cur.planSeen = true
+			cur.planLast = line.PlanLast
+		case tap.TestOK, tap.TestNOK, tap.TestSkip, tap.TestTodo:
+			if pc := cur.pendingChild; pc != nil {
+				cur.pendingChild = nil
+				wantOK := len(pc.failures) == 0
+				if line.OK != wantOK || (line.Description != "" && line.Description != pc.name) {
+					glyph := ""
+					if opts.Glyphs {
+						glyph = render.GlyphNOK + " "
+					}
+					cmap[tap.PlanNOK].Printf("%sSubtest %q summary %q doesn't match its inner plan\n",
+						glyph, pc.name, padding+dedented)
+					if exitCode < planFailExitCode {
+						exitCode = planFailExitCode
+					}
+				}
+			}
+			if line.TestNum > 0 {
+				cur.testnum = line.TestNum
+			} else {
+				cur.testnum++
+			}
+			if rep != nil && depth == 0 {
+				flushPendingRec()
+				pendingRec = &testRecord{
+					Num:         cur.testnum,
+					OK:          line.OK,
+					Description: line.Description,
+					Directive:   line.Directive,
+				}
+			}
+			switch line.Type {
+			case tap.TestNOK:
+				cur.failures = append(cur.failures, cur.testnum)
+				if exitCode < testFailExitCode {
+					exitCode = testFailExitCode
+				}
+				if triageIn != nil {
+					pendingTriage = true
+				}
+			case tap.TestSkip:
+				cur.skipped++
+			case tap.TestTodo:
+				cur.todoCount++
+				if line.OK {
+					cur.todoPassed++
+				}
+			}
+			lastTestType = line.Type
+		case tap.Bail:
+			if exitCode < bailExitCode {
+				exitCode = bailExitCode
+			}
+		case tap.Diagnostic:
+			if depth == 0 && pendingRec != nil {
+				pendingRec.Diagnostics = append(pendingRec.Diagnostics, dedented)
+			}
+			lastTestType = tap.Unknown
+		default:
+			lastTestType = tap.Unknown
+		}
+	}
+
+	if pendingTriage {
+		if promptTriage(triageIn, ttyFile, ofh, pendingDiag, cmap) == triageQuit {
+			quit = true
+		}
+	}
+
+doneScanning:
+	flushPendingRec()
+	exitCode = printAppends(root.failures, root.testnum, root.planLast, exitCode,
+		root.skipped, root.todoCount, root.todoPassed, subtestResults, cmap, opts)
+
+	return runResult{exitCode: exitCode, testnum: root.testnum, failed: len(root.failures), quit: quit}, nil
+}
+
+// resolveTapFiles expands opts.Args.TapFiles into a concrete, sorted list
+// of files to process. With --recurse, each argument is treated as a
+// directory to walk, collecting files matching --glob (default "*.tap").
+// An empty result means "read from stdin".
+func resolveTapFiles(opts options) ([]string, error) {
+	if !opts.Recurse {
+		return opts.Args.TapFiles, nil
+	}
+	pattern := opts.Glob
+	if pattern == "" {
+		pattern = defaultGlob
+	}
+	var files []string
+	for _, dir := range opts.Args.TapFiles {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// runAggregate runs each of files through runStream in turn, printing a
+// coloured header before each and, under --summary, a combined
+// Test::Harness-style "Files=…, Tests=…, Failed=…" summary at the end. The
+// returned exit code is the max of the individual files' exit codes.
+func runAggregate(opts options, files []string, ofh io.Writer) (int, error) {
+	color.SetOutput(ofh)
+	if _, ok := os.LookupEnv("CI"); ok {
+		color.ForceOpenColor()
+	}
+	cmap := getColourMap(opts)
+
+	exitCode := 0
+	var results []runResult
+	var reports []reporter
+	for _, file := range files {
+		cmap[tap.FileHeader].Printf("=== %s ===\n", file)
+
+		fileOpts := opts
+		fileOpts.Args.TapFiles = []string{file}
+		var rep reporter
+		if opts.Junit != "" {
+			rep = newJunitReporter(file)
+			reports = append(reports, rep)
+		}
+		res, err := runStream(fileOpts, ofh, rep)
+		if err != nil {
+			return 0, err
+		}
+		results = append(results, res)
+		if res.exitCode > exitCode {
+			exitCode = res.exitCode
+		}
+		if res.quit {
+			break
+		}
+	}
+
+	if opts.Junit != "" {
+		if err := writeJunitReport(opts.Junit, reports); err != nil {
+			return 0, err
+		}
+	}
+
+	if opts.Summary {
+		totalTests, totalFailed := 0, 0
+		for i, res := range results {
+			totalTests += res.testnum
+			totalFailed += res.failed
+			status := cmap[tap.SummaryOK]
+			if res.failed > 0 {
+				status = cmap[tap.SummaryNOK]
+			}
+			status.Printf("  %s: Failed %d/%d\n", files[i], res.failed, res.testnum)
+		}
+		overall := cmap[tap.SummaryOK]
+		if totalFailed > 0 {
+			overall = cmap[tap.SummaryNOK]
+		}
+		overall.Printf("Files=%d, Tests=%d, Failed=%d\n", len(files), totalTests, totalFailed)
+	}
+
+	return exitCode, nil
+}
+
+// testRecord is a single decoded TAP test result, passed to an Emitter's
+// OnTest. Diagnostics holds the root-level "#" lines that follow the test
+// line, up to the next test line (TAP producers often emit free-form
+// diagnostics instead of, or alongside, a YAML block); runStream fills it
+// in for --junit, while scanTAP leaves it empty and passes those lines to
+// OnDiag instead.
+type testRecord struct {
+	Num         int
+	OK          bool
+	Description string
+	Directive   string
+	Diagnostics []string
+	YAML        *tap.YAMLDiag
+}
+
+// Emitter renders a decoded TAP stream. scanTAP drives an Emitter from a
+// linear, non-subtest-aware pass over the input; the richer coloured
+// renderer used by runStream/runAggregate handles subtest nesting itself
+// and does not go through an Emitter.
+type Emitter interface {
+	OnVersion(version int)
+	OnPlan(first, last int, directive string)
+	OnTest(rec testRecord)
+	OnDiag(text string)
+	OnBail(reason string)
+	OnEnd(res runResult)
+}
+
+// scanTAP reads a TAP stream from r and drives emit, decoupled from any
+// particular rendering. It understands the plan, version, test, bail and
+// "#" diagnostic line types, plus trailing TAP 13/14 YAML diagnostic
+// blocks (via tap.Parse), but (unlike runStream) does not track subtest
+// nesting.
+func scanTAP(r io.Reader, emit Emitter) error {
+	var planLast int
+	testnum := 0
+	failures := 0
+	exitCode := 0
+
+	err := tap.Parse(r, func(text string, line tap.Line) {
+		switch line.Type {
+		case tap.Version:
+			emit.OnVersion(0)
+		case tap.Plan:
+			planLast = line.PlanLast
+			emit.OnPlan(line.PlanFirst, line.PlanLast, "")
+		case tap.TestOK, tap.TestNOK, tap.TestSkip, tap.TestTodo:
+			if line.TestNum > 0 {
+				testnum = line.TestNum
+			} else {
+				testnum++
+			}
+			if line.Type == tap.TestNOK {
+				failures++
+				if exitCode < testFailExitCode {
+					exitCode = testFailExitCode
+				}
+			}
+			emit.OnTest(testRecord{
+				Num:         testnum,
+				OK:          line.OK,
+				Description: line.Description,
+				Directive:   line.Directive,
+				YAML:        line.YAML,
+			})
+		case tap.Diagnostic:
+			emit.OnDiag(text)
+		case tap.Bail:
+			emit.OnBail(text)
+			if exitCode < bailExitCode {
+				exitCode = bailExitCode
+			}
+		}
+	})
+
+	if planNOK := testnum == 0 || testnum != planLast; planNOK && exitCode < planFailExitCode {
+		exitCode = planFailExitCode
+	}
+
+	emit.OnEnd(runResult{exitCode: exitCode, testnum: testnum, failed: failures})
+	return err
+}
+
+// junitTestCase and junitTestSuite are the single JUnit XML model shared
+// by both --format junit (junitEmitter) and --junit FILE (junitReporter):
+// one schema covering the subset of the de facto JUnit XML format CI
+// systems (Jenkins, GitLab, GitHub Actions) actually read, with fields
+// that not every producer populates left as optional
+// rather than forked into two near-identical types. Only junitReporter
+// populates SystemOut.
+type junitTestCase struct {
+	XMLName xml.Name      `xml:"testcase"`
+	Name    string        `xml:"name,attr"`
+	Time    string        `xml:"time,attr,omitempty"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+	Skipped *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitClassify fills in tc's <skipped>/<failure> element from rec's
+// directive/OK state, the classification shared by both JUnit writers: a
+// SKIP directive, or a TODO directive on a failing test (an expected
+// failure, not a real one), renders as <skipped>; any other failing test
+// renders as <failure> with the caller-supplied message/body (the two
+// writers derive that text differently, depending on what their own
+// code path has available: see junitEmitter.OnTest/junitReporter.addTest).
+func junitClassify(tc *junitTestCase, rec testRecord, failMsg, failText string) {
+	switch {
+	case strings.HasPrefix(strings.ToUpper(rec.Directive), "SKIP"):
+		tc.Skipped = &junitSkipped{Message: rec.Directive}
+	case strings.HasPrefix(strings.ToUpper(rec.Directive), "TODO") && !rec.OK:
+		tc.Skipped = &junitSkipped{Message: rec.Directive}
+	case !rec.OK:
+		tc.Failure = &junitFailure{Message: failMsg, Text: failText}
+	}
+}
+
+// countJunitOutcomes tallies how many of cases ended up with a <failure>
+// or <skipped> element, for a testsuite's summary attributes.
+func countJunitOutcomes(cases []junitTestCase) (failures, skipped int) {
+	for _, tc := range cases {
+		if tc.Failure != nil {
+			failures++
+		}
+		if tc.Skipped != nil {
+			skipped++
+		}
+	}
+	return failures, skipped
+}
+
+// junitEmitter collects test records into a junitTestSuite, available via
+// suite() once OnEnd has been called. Unlike junitReporter it is fed by
+// scanTAP rather than runStream, so it also implements Emitter directly;
+// runEmit writes its suite(s) out once every input file has been read,
+// the same way writeJunitReport does for junitReporter.
+type junitEmitter struct {
+	name   string
+	cases  []junitTestCase
+	result junitTestSuite
+}
+
+func newJUnitEmitter(name string) *junitEmitter {
+	if name == "" {
+		name = "ctap"
+	}
+	return &junitEmitter{name: name}
+}
+
+func (e *junitEmitter) OnVersion(int)                    {}
+func (e *junitEmitter) OnPlan(first, last int, _ string) {}
+
+func (e *junitEmitter) OnTest(rec testRecord) {
+	tc := junitTestCase{Name: rec.Description, Time: durationSeconds(rec.YAML)}
+	msg := "not ok"
+	var text string
+	if rec.YAML != nil {
+		if rec.YAML.Message != "" {
+			msg = rec.YAML.Message
+		}
+		text = fmt.Sprintf("got: %v\nexpected: %v", rec.YAML.Got, rec.YAML.Expected)
+	}
+	junitClassify(&tc, rec, msg, text)
+	e.cases = append(e.cases, tc)
+}
+
+func (e *junitEmitter) OnDiag(text string)   {}
+func (e *junitEmitter) OnBail(reason string) {}
+
+func (e *junitEmitter) OnEnd(res runResult) {
+	_, skipped := countJunitOutcomes(e.cases)
+	e.result = junitTestSuite{
+		Name:      e.name,
+		Tests:     res.testnum,
+		Failures:  res.failed,
+		Skipped:   skipped,
+		TestCases: e.cases,
+	}
+}
+
+func (e *junitEmitter) suite() junitTestSuite { return e.result }
+
+// reporter accumulates decoded test results as a TAP stream is coloured,
+// so an alternate-format report can be written once the input has been
+// fully consumed, without disturbing the coloured stream itself. Other
+// report formats (e.g. JSON, SubUnit) can implement the same interface.
+type reporter interface {
+	addTest(rec testRecord)
+	addRaw(line string)
+	suite() junitTestSuite
+}
+
+// junitReporter is the reporter behind --junit.
+type junitReporter struct {
+	name  string
+	cases []junitTestCase
+	raw   strings.Builder
+}
+
+func newJunitReporter(name string) *junitReporter {
+	return &junitReporter{name: name}
+}
+
+func (r *junitReporter) addRaw(line string) {
+	r.raw.WriteString(line)
+	r.raw.WriteByte('\n')
+}
+
+func (r *junitReporter) addTest(rec testRecord) {
+	tc := junitTestCase{Name: rec.Description, Time: durationSeconds(rec.YAML)}
+	msg := "not ok"
+	if rec.YAML != nil && rec.YAML.Message != "" {
+		msg = rec.YAML.Message
+	}
+	text := strings.Join(rec.Diagnostics, "\n")
+	junitClassify(&tc, rec, msg, text)
+	r.cases = append(r.cases, tc)
+}
+
+func (r *junitReporter) suite() junitTestSuite {
+	failures, skipped := countJunitOutcomes(r.cases)
+	return junitTestSuite{
+		Name:      r.name,
+		Tests:     len(r.cases),
+		Failures:  failures,
+		Skipped:   skipped,
+		TestCases: r.cases,
+		SystemOut: r.raw.String(),
+	}
+}
+
+// durationSeconds looks for a "duration_ms" key in a test's YAML
+// diagnostic block and renders it as a JUnit time="" value (seconds);
+// tests with no such key report a time of "0".
+func durationSeconds(diag *tap.YAMLDiag) string {
+	if diag == nil {
+		return "0"
+	}
+	data, ok := diag.Data.(map[string]interface{})
+	if !ok {
+		return "0"
+	}
+	var ms float64
+	switch v := data["duration_ms"].(type) {
+	case int:
+		ms = float64(v)
+	case float64:
+		ms = v
+	default:
+		return "0"
+	}
+	return fmt.Sprintf("%0.3f", ms/1000)
+}
+
+// reportName derives a JUnit testsuite name from the input file, or
+// "stdin" when reading from standard input.
+func reportName(tapFiles []string) string {
+	if len(tapFiles) > 0 {
+		return tapFiles[0]
+	}
+	return "stdin"
+}
+
+// writeJunitReport writes the accumulated reports to path, wrapping them
+// in a <testsuites> element when there's more than one (e.g. under
+// --recurse).
+func writeJunitReport(path string, reps []reporter) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	suites := make([]junitTestSuite, len(reps))
+	for i, r := range reps {
+		suites[i] = r.suite()
+	}
+	return writeJunitXML(f, suites)
+}
+
+// writeJunitXML writes suites as a single <testsuite> document, or (when
+// there's more than one, e.g. --format junit run over several files) a
+// <testsuites> wrapper around them. Shared by writeJunitReport (--junit
+// FILE) and runEmit (--format junit).
+func writeJunitXML(w io.Writer, suites []junitTestSuite) error {
+	var out []byte
+	var err error
+	if len(suites) == 1 {
+		out, err = xml.MarshalIndent(suites[0], "", "  ")
+	} else {
+		out, err = xml.MarshalIndent(junitTestSuites{Suites: suites}, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, xml.Header+string(out))
+	return err
+}
+
+// jsonRecord is the one-line-per-test shape written by jsonEmitter.
+type jsonRecord struct {
+	Num         int           `json:"num"`
+	OK          bool          `json:"ok"`
+	Description string        `json:"description"`
+	Directive   string        `json:"directive,omitempty"`
+	YAML        *tap.YAMLDiag `json:"yaml,omitempty"`
+}
+
+// jsonEmitter writes one JSON object per test line, newline-delimited,
+// so output can be streamed and piped through tools like jq.
+type jsonEmitter struct {
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func newJSONEmitter(w io.Writer) *jsonEmitter {
+	return &jsonEmitter{w: w, enc: json.NewEncoder(w)}
+}
+
+func (e *jsonEmitter) OnVersion(int)                    {}
+func (e *jsonEmitter) OnPlan(first, last int, _ string) {}
+
+func (e *jsonEmitter) OnTest(rec testRecord) {
+	e.enc.Encode(jsonRecord{
+		Num:         rec.Num,
+		OK:          rec.OK,
+		Description: rec.Description,
+		Directive:   rec.Directive,
+		YAML:        rec.YAML,
+	})
+}
+
+func (e *jsonEmitter) OnDiag(text string)   {}
+func (e *jsonEmitter) OnBail(reason string) {}
+func (e *jsonEmitter) OnEnd(res runResult)  {}
+
+// runEmit drives opts.Format ("junit" or "json") over files, the same
+// resolved list (via resolveTapFiles) that runAggregate uses for --format
+// tty, so multiple file arguments and --recurse are honoured here too
+// instead of only ever reading the first file. --format tty itself goes
+// through runCLI/runStream instead, which (unlike the generic scanTAP
+// pipeline) understands subtest nesting.
+//
+// Multiple files are aggregated the same way --junit FILE --recurse
+// aggregates: --format json concatenates each file's JSON lines in turn,
+// and --format junit wraps one <testsuite> per file in a <testsuites>
+// document (see writeJunitXML).
+func runEmit(opts options, files []string, ofh io.Writer) (int, error) {
+	if opts.Format != "junit" && opts.Format != "json" {
+		return 0, fmt.Errorf("runEmit: unsupported format %q", opts.Format)
+	}
+	if len(files) == 0 {
+		files = []string{""}
+	}
+
+	exitCode := 0
+	var suites []junitTestSuite
+	jsonEmit := newJSONEmitter(ofh)
+
+	// fail flushes whatever junit suites were completed before err
+	// occurred (if any — don't emit an empty report when nothing
+	// succeeded) and returns err, so a later file's failure doesn't
+	// silently discard earlier files' results.
+	fail := func(err error) (int, error) {
+		if opts.Format == "junit" && len(suites) > 0 {
+			if werr := writeJunitXML(ofh, suites); werr != nil {
+				return 0, fmt.Errorf("%w (also failed to write partial junit report: %v)", err, werr)
+			}
+		}
+		return 0, err
+	}
+
+	for _, file := range files {
+		var fh *os.File
+		var err error
+		if file != "" {
+			fh, err = os.Open(file)
+			if err != nil {
+				return fail(err)
+			}
+		} else {
+			fh = os.Stdin
+		}
+
+		input, err := openTAPInput(opts, fh)
+		if err != nil {
+			if file != "" {
+				fh.Close()
+			}
+			return fail(err)
+		}
+
+		var result runResult
+		var emit Emitter
+		var junitEmit *junitEmitter
+		if opts.Format == "junit" {
+			junitEmit = newJUnitEmitter(file)
+			emit = junitEmit
+		} else {
+			emit = jsonEmit
+		}
+
+		capture := &resultCapturingEmitter{Emitter: emit, out: &result}
+		err = scanTAP(input, capture)
+		if file != "" {
+			fh.Close()
+		}
+
+		// scanTAP always calls OnEnd before returning an error, so
+		// junitEmit's suite is populated even on a scan error; flush
+		// what's been collected so far (for this and any earlier files)
+		// rather than silently dropping the whole report, mirroring how
+		// scanTAP itself flushes a partial result (see af6afef).
+		if result.exitCode > exitCode {
+			exitCode = result.exitCode
+		}
+		if junitEmit != nil {
+			suites = append(suites, junitEmit.suite())
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if opts.Format == "junit" {
+		if err := writeJunitXML(ofh, suites); err != nil {
+			return 0, err
+		}
+	}
+
+	return exitCode, nil
+}
+
+// resultCapturingEmitter wraps an Emitter to capture the final runResult
+// passed to OnEnd, so runEmit can report an exit code.
+type resultCapturingEmitter struct {
+	Emitter
+	out *runResult
+}
+
+func (c *resultCapturingEmitter) OnEnd(res runResult) {
+	*c.out = res
+	c.Emitter.OnEnd(res)
+}
+
+func main() {
+	// Parse default options are HelpFlag | PrintErrors | PassDoubleDash
+	parser := flags.NewParser(&opts, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if flags.WroteHelp(err) {
+			fmt.Print(usageAddendum)
+			os.Exit(0)
+		}
+
+		// Does PrintErrors work? Is it not set?
+		fmt.Fprintln(os.Stderr, "")
+		parser.WriteHelp(os.Stderr)
+		os.Exit(2)
+	}
+
+	var cfg userConfig
+	if opts.Theme != "" || opts.ListThemes {
+		path, err := userConfigPath()
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg, err = loadUserConfig(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if opts.ListThemes {
+		for _, name := range themeNames(cfg) {
+			fmt.Println(name)
+		}
+		os.Exit(0)
+	}
+
+	if opts.Theme != "" {
+		t, ok := lookupTheme(cfg, opts.Theme)
+		if !ok {
+			log.Fatalf("unknown theme %q (see --list-themes)", opts.Theme)
+		}
+		applyTheme(&opts, t)
+	}
+
+	files, err := resolveTapFiles(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var exitCode int
+	switch {
+	case opts.Format == "junit" || opts.Format == "json":
+		exitCode, err = runEmit(opts, files, os.Stdout)
+	case len(files) <= 1:
+		cliOpts := opts
+		cliOpts.Args.TapFiles = files
+		exitCode, err = runCLI(cliOpts, os.Stdout)
+	default:
+		exitCode, err = runAggregate(opts, files, os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
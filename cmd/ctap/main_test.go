@@ -0,0 +1,731 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/diff"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gavincarr/ctap/tap"
+)
+
+var update *bool
+
+func init() {
+	testing.Init()
+	update = flag.Bool("u", false, "update .golden files")
+	flag.Parse()
+}
+
+func TestBasic(t *testing.T) {
+	var tests = []struct {
+		name     string
+		infile   string
+		outfile  string
+		exitCode int
+		flags    string
+	}{
+		{"test1", "test1.txt", "test1.txt", 0, ""},
+		{"test2", "test2.txt", "test2.txt", 3, ""},
+		{"test3", "test3.txt", "test3.txt", 4, ""},
+		{"test4", "test4.txt", "test4.txt", 4, ""},
+		{"test5", "test5.txt", "test5.txt", 5, ""},
+		// -s
+		{"test1 -s", "test1.txt", "test1s.txt", 0, "s"},
+		{"test2 -s", "test2.txt", "test2s.txt", 3, "s"},
+		{"test3 -s", "test3.txt", "test3s.txt", 4, "s"},
+		{"test4 -s", "test4.txt", "test4s.txt", 4, "s"},
+		{"test5 -s", "test5.txt", "test5s.txt", 5, "s"},
+		// -f
+		{"test1 -f", "test1.txt", "test1f.txt", 0, "f"},
+		{"test2 -f", "test2.txt", "test2f.txt", 3, "f"},
+		// -g
+		{"test1 -g", "test1.txt", "test1g.txt", 0, "g"},
+		{"test2 -g", "test2.txt", "test2g.txt", 3, "g"},
+		{"test3 -g", "test3.txt", "test3g.txt", 4, "g"},
+		{"test4 -g", "test4.txt", "test4g.txt", 4, "g"},
+		{"test5 -g", "test5.txt", "test5g.txt", 5, "g"},
+		// Combos
+		{"test1 -fs", "test1.txt", "test1fs.txt", 0, "fs"},
+		{"test2 -fs", "test2.txt", "test2fs.txt", 3, "fs"},
+		{"test1 -gs", "test1.txt", "test1gs.txt", 0, "gs"},
+		{"test2 -gs", "test2.txt", "test2gs.txt", 3, "gs"},
+		{"test3 -gs", "test3.txt", "test3gs.txt", 4, "gs"},
+		{"test4 -gs", "test4.txt", "test4gs.txt", 4, "gs"},
+		{"test5 -gs", "test5.txt", "test5gs.txt", 5, "gs"},
+		{"test1 -fgs", "test1.txt", "test1fgs.txt", 0, "fgs"},
+		{"test2 -fgs", "test2.txt", "test2fgs.txt", 3, "fgs"},
+		{"test3 -fgs", "test3.txt", "test3fgs.txt", 4, "fgs"},
+		{"test4 -fgs", "test4.txt", "test4fgs.txt", 4, "fgs"},
+		{"test5 -fgs", "test5.txt", "test5fgs.txt", 5, "fgs"},
+		// Version 13
+		{"test13", "test13.txt", "test13.txt", 0, ""},
+		{"test14", "test14.txt", "test14.txt", 3, ""},
+		// Empty
+		{"test0", "test0.txt", "test0.txt", 4, ""},
+		{"empty", "empty.txt", "empty.txt", 4, ""},
+	}
+
+	reNL := regexp.MustCompile("\r?\n")
+
+	for _, tc := range tests {
+		opts := options{}
+		if strings.Contains(tc.flags, "f") {
+			opts.Failures = true
+		}
+		if strings.Contains(tc.flags, "g") {
+			opts.Glyphs = true
+		}
+		if strings.Contains(tc.flags, "s") {
+			opts.Summary = true
+		}
+		opts.Args.TapFiles = []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		code, err := runCLI(opts, buf)
+		if err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+		assert.Equal(t, tc.exitCode, code, tc.name+" exitCode")
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if runtime.GOOS == "windows" {
+			// For Windows tests, normalise line endings
+			got = reNL.ReplaceAll(got, []byte("\n"))
+			exp = reNL.ReplaceAll(exp, []byte("\n"))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestCustomColours(t *testing.T) {
+	var tests = []struct {
+		name    string
+		infile  string
+		outfile string
+		opts    options
+	}{
+		{"test1", "test1.txt", "test1cc1.txt",
+			options{COk: "#339933", CFail: "bold c60",
+				CPlan: "yellow bold", CDiag: "#939"},
+		},
+		{"test2", "test2.txt", "test2cc1.txt",
+			options{COk: "#339933", CFail: "bold c60",
+				CPlan: "yellow bold", CDiag: "#939"},
+		},
+		{"test5", "test5.txt", "test5cc1.txt",
+			options{COk: "#339933", CFail: "bold c60",
+				CPlan: "yellow bold", CDiag: "#939",
+				CBail: "yellow bold reverse blink"},
+		},
+	}
+
+	reNL := regexp.MustCompile("\r?\n")
+
+	for _, tc := range tests {
+		opts := tc.opts
+		opts.Args.TapFiles = []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		_, err := runCLI(opts, buf)
+		if err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err = ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if runtime.GOOS == "windows" {
+			// For Windows tests, normalise line endings
+			got = reNL.ReplaceAll(got, []byte("\n"))
+			exp = reNL.ReplaceAll(exp, []byte("\n"))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestYAMLDiag(t *testing.T) {
+	var tests = []struct {
+		name    string
+		infile  string
+		outfile string
+		flags   string
+	}{
+		{"test6", "test6.txt", "test6.txt", ""},
+		{"test7", "test7.txt", "test7.txt", ""},
+		{"test7 -f", "test7.txt", "test7f.txt", "f"},
+		{"test6 --no-yaml", "test6.txt", "test6noyaml.txt", "y"},
+	}
+
+	for _, tc := range tests {
+		opts := options{}
+		if strings.Contains(tc.flags, "f") {
+			opts.Failures = true
+		}
+		if strings.Contains(tc.flags, "y") {
+			opts.NoYAML = true
+		}
+		opts.Args.TapFiles = []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		_, err := runCLI(opts, buf)
+		if err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestSubtests(t *testing.T) {
+	var tests = []struct {
+		name     string
+		infile   string
+		outfile  string
+		exitCode int
+		flags    string
+	}{
+		{"test8", "test8.txt", "test8.txt", 3, ""},
+		{"test8 -s", "test8.txt", "test8s.txt", 3, "s"},
+		{"test10", "test10.txt", "test10.txt", planFailExitCode, ""},
+	}
+
+	for _, tc := range tests {
+		opts := options{}
+		if strings.Contains(tc.flags, "s") {
+			opts.Summary = true
+		}
+		opts.Args.TapFiles = []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		code, err := runCLI(opts, buf)
+		if err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+		assert.Equal(t, tc.exitCode, code, tc.name+" exitCode")
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	files := []string{
+		filepath.Join("testdata", "test1.txt"),
+		filepath.Join("testdata", "test2.txt"),
+	}
+	opts := options{Summary: true}
+	buf := new(bytes.Buffer)
+
+	code, err := runAggregate(opts, files, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testFailExitCode, code, "aggregate exitCode")
+
+	got := buf.Bytes()
+	golden := filepath.Join("testdata", "golden", "aggregate1.txt")
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+		}
+		return
+	}
+
+	exp, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%s: %s", err.Error(), string(exp))
+	}
+	if !bytes.Equal(got, exp) {
+		t.Errorf("aggregate test failed:\n%s\n", diff.Diff(string(exp), string(got)))
+	}
+}
+
+func TestPromptTriage(t *testing.T) {
+	cmap := getColourMap(options{})
+	diag := &tap.YAMLDiag{Got: "3\n", Expected: "4\n"}
+
+	var tests = []struct {
+		name   string
+		input  string
+		action triageAction
+	}{
+		{"continue", "c\n", triageContinue},
+		{"default is continue", "\n", triageContinue},
+		{"skip", "s\n", triageSkipFile},
+		{"quit", "q\n", triageQuit},
+		{"diff then continue", "d\nc\n", triageContinue},
+		{"eof is quit", "", triageQuit},
+	}
+
+	for _, tc := range tests {
+		in := bufio.NewReader(strings.NewReader(tc.input))
+		buf := new(bytes.Buffer)
+		action := promptTriage(in, nil, buf, diag, cmap)
+		assert.Equal(t, tc.action, action, tc.name)
+	}
+}
+
+func TestStructuredFormats(t *testing.T) {
+	var tests = []struct {
+		name    string
+		infile  string
+		outfile string
+		format  string
+	}{
+		{"test2 junit", "test2.txt", "test2.junit.xml", "junit"},
+		{"test6 junit", "test6.txt", "test6.junit.xml", "junit"},
+		{"test9 junit", "test9.txt", "test9.junit.xml", "junit"},
+		{"test2 json", "test2.txt", "test2.json", "json"},
+		{"test6 json", "test6.txt", "test6.json", "json"},
+		{"test9 json", "test9.txt", "test9.json", "json"},
+	}
+
+	for _, tc := range tests {
+		opts := options{Format: tc.format}
+		files := []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		if _, err := runEmit(opts, files, buf); err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestStructuredFormatsMultiFile(t *testing.T) {
+	var tests = []struct {
+		name    string
+		infiles []string
+		outfile string
+		format  string
+	}{
+		{"test2+test6 junit", []string{"test2.txt", "test6.txt"}, "test2+test6.junit.xml", "junit"},
+		{"test2+test6 json", []string{"test2.txt", "test6.txt"}, "test2+test6.json", "json"},
+	}
+
+	for _, tc := range tests {
+		opts := options{Format: tc.format}
+		var files []string
+		for _, f := range tc.infiles {
+			files = append(files, filepath.Join("testdata", f))
+		}
+		buf := new(bytes.Buffer)
+
+		if _, err := runEmit(opts, files, buf); err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+// TestStructuredFormatsPartialFailure checks that runEmit writes out the
+// junit suites it already completed when a later file fails to open,
+// rather than silently discarding them (see af6afef/the runEmit fail
+// helper).
+func TestStructuredFormatsPartialFailure(t *testing.T) {
+	opts := options{Format: "junit"}
+	files := []string{
+		filepath.Join("testdata", "test2.txt"),
+		filepath.Join("testdata", "does-not-exist.tap"),
+	}
+	buf := new(bytes.Buffer)
+
+	_, err := runEmit(opts, files, buf)
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), `<testsuite name="testdata/test2.txt"`)
+}
+
+func TestThemes(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	data := []byte(`
+themes:
+  custom:
+    ok: blue
+    fail: "magenta bold"
+  dark:
+    ok: "#00ff00"
+`)
+	if err := ioutil.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadUserConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	custom, ok := lookupTheme(cfg, "custom")
+	if !ok {
+		t.Fatal("expected custom theme to be found")
+	}
+	opts := options{}
+	applyTheme(&opts, custom)
+	assert.Equal(t, "blue", opts.COk)
+	assert.Equal(t, "magenta bold", opts.CFail)
+
+	// CLI flags take precedence over theme values
+	cliOpts := options{COk: "red"}
+	applyTheme(&cliOpts, custom)
+	assert.Equal(t, "red", cliOpts.COk)
+
+	// A user config theme overrides a builtin of the same name
+	dark, ok := lookupTheme(cfg, "dark")
+	if !ok {
+		t.Fatal("expected dark theme to be found")
+	}
+	assert.Equal(t, "#00ff00", dark.Ok)
+
+	// Builtin themes are available with no user config at all
+	if _, ok := lookupTheme(userConfig{}, "light"); !ok {
+		t.Fatal("expected builtin light theme to be found")
+	}
+
+	names := themeNames(cfg)
+	assert.Contains(t, names, "custom")
+	assert.Contains(t, names, "light")
+	assert.Contains(t, names, "solarized")
+
+	// A missing config file behaves like an empty one, not an error
+	missing, err := loadUserConfig(filepath.Join(dir, "nope.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, missing.Themes)
+}
+
+func TestThemeColours(t *testing.T) {
+	var tests = []struct {
+		name    string
+		infile  string
+		outfile string
+		theme   string
+	}{
+		{"test1 solarized", "test1.txt", "test1theme.txt", "solarized"},
+		{"test2 solarized", "test2.txt", "test2theme.txt", "solarized"},
+		{"test9 solarized", "test9.txt", "test9theme.txt", "solarized"},
+	}
+
+	for _, tc := range tests {
+		theme, ok := lookupTheme(userConfig{}, tc.theme)
+		if !ok {
+			t.Fatalf("theme %q not found", tc.theme)
+		}
+		opts := options{}
+		applyTheme(&opts, theme)
+		opts.Args.TapFiles = []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		_, err := runCLI(opts, buf)
+		if err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestSkipTodo(t *testing.T) {
+	var tests = []struct {
+		name     string
+		outfile  string
+		exitCode int
+		summary  bool
+	}{
+		{"test9", "test9.txt", testFailExitCode, false},
+		{"test9 -s", "test9s.txt", testFailExitCode, true},
+	}
+
+	reNL := regexp.MustCompile("\r?\n")
+
+	for _, tc := range tests {
+		opts := options{Summary: tc.summary}
+		opts.Args.TapFiles = []string{filepath.Join("testdata", "test9.txt")}
+		buf := new(bytes.Buffer)
+
+		code, err := runCLI(opts, buf)
+		if err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+		assert.Equal(t, tc.exitCode, code, tc.name+" exitCode")
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if runtime.GOOS == "windows" {
+			got = reNL.ReplaceAll(got, []byte("\n"))
+			exp = reNL.ReplaceAll(exp, []byte("\n"))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestJunitReport(t *testing.T) {
+	var tests = []struct {
+		name    string
+		infile  string
+		outfile string
+		from    string
+	}{
+		{"test7", "test7.txt", "test7.junit-report.xml", ""},
+		{"test9", "test9.txt", "test9.junit-report.xml", ""},
+		{"test15 gotest", "test15.json", "test15.junit-report.xml", "gotest"},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		report := filepath.Join(dir, "report.xml")
+
+		opts := options{Junit: report, From: tc.from}
+		opts.Args.TapFiles = []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		if _, err := runCLI(opts, buf); err != nil {
+			t.Error(err)
+		}
+		got, err := ioutil.ReadFile(report)
+		if err != nil {
+			t.Fatalf("%s: reading report: %s", tc.name, err)
+		}
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestGotestAdapter(t *testing.T) {
+	var tests = []struct {
+		name     string
+		infile   string
+		outfile  string
+		from     string
+		exitCode int
+	}{
+		{"auto-detect", "test15.json", "test15.txt", "", 3},
+		{"explicit from=gotest", "test15.json", "test15.txt", "gotest", 3},
+	}
+
+	for _, tc := range tests {
+		opts := options{From: tc.from, Summary: true}
+		opts.Args.TapFiles = []string{filepath.Join("testdata", tc.infile)}
+		buf := new(bytes.Buffer)
+
+		code, err := runCLI(opts, buf)
+		if err != nil {
+			t.Error(err)
+		}
+		got := buf.Bytes()
+		assert.Equal(t, tc.exitCode, code, tc.name+" exitCode")
+
+		golden := filepath.Join("testdata", "golden", tc.outfile)
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+			}
+			continue
+		}
+
+		exp, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s", err.Error(), string(exp))
+		}
+		if !bytes.Equal(got, exp) {
+			t.Errorf("test %q failed:\n%s\n", tc.name,
+				diff.Diff(string(exp), string(got)))
+		}
+	}
+}
+
+func TestGotestAdapterBadInput(t *testing.T) {
+	opts := options{From: "gotest"}
+	opts.Args.TapFiles = []string{filepath.Join("testdata", "test1.txt")}
+	buf := new(bytes.Buffer)
+
+	_, err := runCLI(opts, buf)
+	assert.Error(t, err)
+}
+
+// TestRecurseSingleMatch covers a --recurse directory argument that
+// resolves to a single file: the resolved file, not the original
+// directory argument, must be what gets read.
+func TestRecurseSingleMatch(t *testing.T) {
+	opts := options{Recurse: true, Summary: true}
+	opts.Args.TapFiles = []string{filepath.Join("testdata", "recurse1")}
+
+	files, err := resolveTapFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{filepath.Join("testdata", "recurse1", "only.tap")}, files)
+
+	cliOpts := opts
+	cliOpts.Args.TapFiles = files
+	buf := new(bytes.Buffer)
+	code, err := runCLI(cliOpts, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, code, "exitCode")
+
+	golden := filepath.Join("testdata", "golden", "recurse1.txt")
+	got := buf.Bytes()
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatalf("failed to update golden file %q: %s\n", golden, err)
+		}
+		return
+	}
+
+	exp, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%s: %s", err.Error(), string(exp))
+	}
+	if !bytes.Equal(got, exp) {
+		t.Errorf("recurse single match test failed:\n%s\n", diff.Diff(string(exp), string(got)))
+	}
+}
@@ -0,0 +1,198 @@
+// Package render colourises a decoded TAP stream (see
+// github.com/gavincarr/ctap/tap) for terminal display. A Theme supplies
+// the colour string for each kind of line; callers that don't want to
+// build their own can start from DefaultTheme().
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gookit/color"
+
+	"github.com/gavincarr/ctap/tap"
+)
+
+const (
+	GlyphOK  = "✓"
+	GlyphNOK = "✗"
+)
+
+// ReTestPrefix matches the leading "ok"/"not ok" of a test line (after
+// any subtest indentation), so --glyphs can replace it in place.
+var ReTestPrefix = regexp.MustCompile(`^(\pZ*)(ok|not ok)\pZ*`)
+
+// Theme is a named colour string for each kind of TAP line. A zero-value
+// field means "use DefaultTheme's colour for this line type", so a caller
+// only needs to set the fields it wants to override.
+type Theme struct {
+	Unknown     string
+	Version     string
+	Plan        string
+	OK          string
+	Fail        string
+	Diag        string
+	Bail        string
+	Skip        string
+	Todo        string
+	YAML        string
+	Stack       string // not read by NewColourMap: a ColourMap has no stack entry
+	FileHeader  string
+	SummaryOK   string
+	SummaryFail string
+	PlanFail    string
+}
+
+// DefaultTheme is the colour scheme ctap uses when no theme or colour
+// flags are given.
+func DefaultTheme() Theme {
+	return Theme{
+		Unknown:     "default",
+		Version:     "cyan",
+		Plan:        "white",
+		OK:          "green",
+		Fail:        "red bold",
+		Diag:        "gray",
+		Bail:        "yellow bold",
+		Skip:        "yellow",
+		Todo:        "blue",
+		YAML:        "gray",
+		Stack:       "yellow bold",
+		FileHeader:  "cyan bold",
+		SummaryOK:   "green bold",
+		SummaryFail: "red bold",
+		PlanFail:    "magenta bold",
+	}
+}
+
+var (
+	colourStringMap = map[string]color.Color{
+		"red":     color.FgRed,
+		"blue":    color.FgBlue,
+		"green":   color.FgGreen,
+		"yellow":  color.FgYellow,
+		"cyan":    color.FgCyan,
+		"magenta": color.FgMagenta,
+		"white":   color.FgWhite,
+		"black":   color.FgBlack,
+		"gray":    color.FgGray,
+		"default": color.FgDefault,
+	}
+	colourOptMap = map[string]color.Color{
+		"bold":       color.OpBold,
+		"italic":     color.OpItalic,
+		"underscore": color.OpUnderscore,
+		"blink":      color.OpBlink,
+		"concealed":  color.OpConcealed,
+		"fuzzy":      color.OpFuzzy,
+		"reverse":    color.OpReverse,
+	}
+
+	reHexColour = regexp.MustCompile(`(?i)^#?([0-9a-f]{6}|[0-9a-f]{3})$`)
+)
+
+// ParseColour turns a colour string (a name or hex value, with optional
+// space-separated modifiers like "bold") into a printable style.
+func ParseColour(c string) (color.PrinterFace, error) {
+	// Extract colour+options from c
+	var colourStr string
+	var options []color.Color
+	for _, t := range strings.Split(c, " ") {
+		o, ok := colourOptMap[t]
+		if ok {
+			options = append(options, o)
+			continue
+		}
+		// Error if more than one colour found
+		if colourStr != "" {
+			return nil, fmt.Errorf("multiple colours in string %q?", c)
+		}
+		colourStr = t
+	}
+
+	// Convert colour+options to a style
+	if reHexColour.MatchString(colourStr) {
+		style := color.HEXStyle(colourStr)
+		if len(options) > 0 {
+			style.AddOpts(options...)
+		}
+		return style, nil
+	}
+	colour, ok := colourStringMap[colourStr]
+	if !ok {
+		return nil, fmt.Errorf("bad colour string %q", colourStr)
+	}
+	if len(options) > 0 {
+		options = append([]color.Color{colour}, options...)
+		return color.New(options...), nil
+	}
+	return color.New(colour), nil
+}
+
+// ColourMap maps each tap.LineType to the style it should be printed in.
+type ColourMap map[tap.LineType]color.PrinterFace
+
+// NewColourMap builds a ColourMap from theme, falling back to
+// DefaultTheme's colour for any field theme leaves empty.
+func NewColourMap(theme Theme) (ColourMap, error) {
+	def := DefaultTheme()
+	pick := func(want, fallback string) (color.PrinterFace, error) {
+		if want == "" {
+			want = fallback
+		}
+		return ParseColour(want)
+	}
+
+	cmap := make(ColourMap)
+	var err error
+	for lt, pair := range map[tap.LineType][2]string{
+		tap.Unknown:    {theme.Unknown, def.Unknown},
+		tap.Version:    {theme.Version, def.Version},
+		tap.Plan:       {theme.Plan, def.Plan},
+		tap.TestOK:     {theme.OK, def.OK},
+		tap.TestNOK:    {theme.Fail, def.Fail},
+		tap.Diagnostic: {theme.Diag, def.Diag},
+		tap.Bail:       {theme.Bail, def.Bail},
+		tap.TestSkip:   {theme.Skip, def.Skip},
+		tap.TestTodo:   {theme.Todo, def.Todo},
+		tap.YAMLBlock:  {theme.YAML, def.YAML},
+		tap.FileHeader: {theme.FileHeader, def.FileHeader},
+		tap.SummaryOK:  {theme.SummaryOK, def.SummaryOK},
+		tap.SummaryNOK: {theme.SummaryFail, def.SummaryFail},
+		tap.PlanNOK:    {theme.PlanFail, def.PlanFail},
+	} {
+		cmap[lt], err = pick(pair[0], pair[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cmap, nil
+}
+
+// Cprintln prints text in the style registered for linetype, applying the
+// --failures/--glyphs transforms a caller has requested. It panics (via
+// the same lookup-miss path cmd/ctap has always used) if cmap has no
+// style for linetype, which would be a programming error.
+func Cprintln(cmap ColourMap, text string, linetype tap.LineType, failuresOnly, glyphs bool) {
+	if failuresOnly && linetype == tap.TestOK {
+		return
+	}
+	if glyphs {
+		switch linetype {
+		case tap.TestOK, tap.TestNOK, tap.TestSkip, tap.TestTodo:
+			glyph := GlyphOK
+			if m := ReTestPrefix.FindStringSubmatch(text); m != nil && m[2] == "not ok" {
+				glyph = GlyphNOK
+			}
+			text = ReTestPrefix.ReplaceAllString(text, "${1}"+glyph+" ")
+		case tap.Bail:
+			text = GlyphNOK + " " + text
+		}
+	}
+	cfmt, ok := cmap[linetype]
+	if !ok {
+		panic(fmt.Sprintf("no formatter defined for linetype %q: %s", linetype.String(), text))
+	}
+	cfmt.Println(text)
+}
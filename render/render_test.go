@@ -0,0 +1,46 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gavincarr/ctap/tap"
+)
+
+func TestParseColour(t *testing.T) {
+	var tests = []struct {
+		name    string
+		colour  string
+		wantErr bool
+	}{
+		{"name", "red", false},
+		{"name with modifier", "red bold", false},
+		{"hex", "#cc9900", false},
+		{"short hex", "#c90", false},
+		{"unknown name", "mauve", true},
+		{"two colours", "red blue", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseColour(tt.colour)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewColourMap(t *testing.T) {
+	cmap, err := NewColourMap(Theme{})
+	assert.NoError(t, err)
+	assert.Contains(t, cmap, tap.TestOK)
+	assert.Contains(t, cmap, tap.TestNOK)
+
+	cmap, err = NewColourMap(Theme{Fail: "bad colour"})
+	assert.Error(t, err)
+	assert.Nil(t, cmap)
+}
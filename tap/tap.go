@@ -0,0 +1,217 @@
+// Package tap decodes TAP (Test Anything Protocol) 13/14 lines. It has no
+// rendering concerns of its own (see github.com/gavincarr/ctap/render for
+// colourising a decoded stream), so it can be reused by other Go programs
+// that want to parse TAP output without pulling in a terminal colour
+// library.
+package tap
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LineType classifies a single line of a decoded TAP stream.
+type LineType int
+
+const (
+	Unknown LineType = iota
+	Version
+	Plan
+	TestOK
+	TestNOK
+	TestSkip
+	TestTodo
+	Diagnostic
+	Bail
+	YAMLBlock
+	FileHeader
+	SummaryOK
+	SummaryNOK
+	PlanNOK
+)
+
+func (t LineType) String() string {
+	return [...]string{
+		"Unknown", "Version", "Plan", "TestOK", "TestNOK", "TestSkip", "TestTodo",
+		"Diag", "Bail", "YAMLBlock", "FileHeader", "SummaryOK", "SummaryNOK", "PlanNOK"}[t]
+}
+
+// Line is a single decoded TAP line.
+type Line struct {
+	Type        LineType
+	PlanFirst   int  // Plan
+	PlanLast    int  // Plan
+	TestNum     int  // Test
+	OK          bool // Test: true if the line read "ok" rather than "not ok"
+	Description string
+	Directive   string
+	YAML        *YAMLDiag // Test: trailing YAML diagnostic block, set by Parse
+}
+
+// YAMLDiag is the decoded form of a TAP 13/14 YAML diagnostic block, as
+// documented at https://testanything.org/tap-version-13-specification.html
+type YAMLDiag struct {
+	Message  string      `yaml:"message" json:"message,omitempty"`
+	Severity string      `yaml:"severity" json:"severity,omitempty"`
+	Data     interface{} `yaml:"data" json:"data,omitempty"`
+	Got      interface{} `yaml:"got" json:"got,omitempty"`
+	Expected interface{} `yaml:"expected" json:"expected,omitempty"`
+	Stack    string      `yaml:"stack" json:"stack,omitempty"`
+	At       *YAMLAt     `yaml:"at" json:"at,omitempty"`
+}
+
+type YAMLAt struct {
+	File string `yaml:"file" json:"file,omitempty"`
+	Line int    `yaml:"line" json:"line,omitempty"`
+}
+
+var (
+	reVersion    = regexp.MustCompile(`^TAP version (\d+)`)
+	rePlan       = regexp.MustCompile(`^(\d+)..(\d+)\s*(?:#\s*(.*?)\s*)?$`)
+	reTest       = regexp.MustCompile(`^(ok|not ok)(?:\pZ+(\d+))?(?:\pZ+([^#]+))?(?:\pZ+(#\pZ*(.*?)))?\pZ*?$`)
+	reDiagnostic = regexp.MustCompile(`^#`)
+	reBail       = regexp.MustCompile(`^Bail out!(?:\pZ*(.*?))?\pZ*$`)
+
+	// ReYAMLStart and ReYAMLEnd match the "---"/"..." fences around a TAP
+	// YAML diagnostic block; exported so callers driving their own
+	// subtest/YAML state machine (as cmd/ctap does) can recognise them.
+	ReYAMLStart = regexp.MustCompile(`^\s*---\s*$`)
+	ReYAMLEnd   = regexp.MustCompile(`^\s*\.\.\.\s*$`)
+
+	// ReSubtest matches a TAP 14 "# Subtest: name" nesting marker.
+	ReSubtest = regexp.MustCompile(`^#\s*Subtest:\s*(.*?)\s*$`)
+)
+
+// ParseLine decodes a single line of TAP text.
+func ParseLine(text string) Line {
+	if matches := reVersion.FindStringSubmatch(text); matches != nil {
+		return Line{Type: Version}
+	}
+	if matches := rePlan.FindStringSubmatch(text); matches != nil {
+		line := Line{Type: Plan}
+		if planfirst := matches[1]; planfirst != "" {
+			if i, err := strconv.Atoi(planfirst); err == nil {
+				line.PlanFirst = i
+			}
+		}
+		if planlast := matches[2]; planlast != "" {
+			if i, err := strconv.Atoi(planlast); err == nil {
+				line.PlanLast = i
+			}
+		}
+		return line
+	}
+	if matches := reTest.FindStringSubmatch(text); matches != nil {
+		line := Line{}
+		res := matches[1]
+		if testno := matches[2]; testno != "" {
+			i, err := strconv.Atoi(testno)
+			if err == nil {
+				line.TestNum = i
+			}
+		}
+		switch res {
+		case "ok":
+			line.Type, line.OK = TestOK, true
+		case "not ok":
+			line.Type = TestNOK
+		}
+		line.Description = strings.TrimPrefix(strings.TrimSpace(matches[3]), "- ")
+		line.Directive = matches[5]
+
+		switch directive := strings.ToUpper(line.Directive); {
+		case strings.HasPrefix(directive, "SKIP"):
+			line.Type = TestSkip
+		case strings.HasPrefix(directive, "TODO"):
+			line.Type = TestTodo
+		}
+		return line
+	}
+	if matches := reDiagnostic.FindStringSubmatch(text); matches != nil {
+		return Line{Type: Diagnostic}
+	}
+	if matches := reBail.FindStringSubmatch(text); matches != nil {
+		return Line{Type: Bail}
+	}
+	return Line{Type: Unknown}
+}
+
+// ParseYAMLDiag decodes the body of a TAP 13/14 YAML diagnostic block (the
+// lines between a "---" and "..." fence) into a YAMLDiag.
+func ParseYAMLDiag(lines []string) (YAMLDiag, error) {
+	var diag YAMLDiag
+	err := yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &diag)
+	return diag, err
+}
+
+// Parse reads a full TAP stream from r and calls emit once per top-level
+// decoded line, in stream order, with both the line's raw text and its
+// ParseLine result. Unlike calling ParseLine yourself line-by-line, Parse
+// also recognises a trailing TAP 13/14 YAML diagnostic block ("---"/"...")
+// following a test line, decodes it via ParseYAMLDiag, and attaches it to
+// that test's Line.YAML before emitting it — the one piece of stream-level
+// state ParseLine can't see on its own.
+//
+// Parse does not track TAP 14 subtest nesting: it is a building block for
+// tools that want a decoded TAP stream (a web viewer, an editor plugin, a
+// `go test -json` bridge) without reimplementing this scanning, not a full
+// renderer. cmd/ctap's own coloured, subtest-aware renderer (runStream)
+// does not use Parse, since it also drives interactive failure triage and
+// needs to track the subtest stack itself.
+func Parse(r io.Reader, emit func(text string, line Line)) error {
+	scanner := bufio.NewScanner(r)
+
+	inYAMLBlock := false
+	var yamlLines []string
+	var pendingText string
+	var pending *Line
+
+	flushPending := func() {
+		if pending != nil {
+			emit(pendingText, *pending)
+			pending = nil
+		}
+	}
+
+	for scanner.Scan() {
+		text := scanner.Text()
+
+		if inYAMLBlock {
+			if ReYAMLEnd.MatchString(text) {
+				inYAMLBlock = false
+				if diag, err := ParseYAMLDiag(yamlLines); err == nil && pending != nil {
+					pending.YAML = &diag
+				}
+				flushPending()
+				continue
+			}
+			yamlLines = append(yamlLines, text)
+			continue
+		}
+
+		line := ParseLine(text)
+
+		if line.Type == Unknown && ReYAMLStart.MatchString(text) && pending != nil {
+			inYAMLBlock = true
+			yamlLines = nil
+			continue
+		}
+
+		flushPending()
+
+		switch line.Type {
+		case TestOK, TestNOK, TestSkip, TestTodo:
+			pendingText, pending = text, &line
+		default:
+			emit(text, line)
+		}
+	}
+	flushPending()
+
+	return scanner.Err()
+}
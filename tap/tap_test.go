@@ -0,0 +1,77 @@
+package tap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want Line
+	}{
+		{"version", "TAP version 13", Line{Type: Version}},
+		{"plan", "1..5", Line{Type: Plan, PlanFirst: 1, PlanLast: 5}},
+		{"ok", "ok 1 - addition works",
+			Line{Type: TestOK, OK: true, TestNum: 1, Description: "addition works"}},
+		{"not ok", "not ok 2 - subtraction works",
+			Line{Type: TestNOK, TestNum: 2, Description: "subtraction works"}},
+		{"skip", "ok 3 - slow test # SKIP not ready",
+			Line{Type: TestSkip, OK: true, TestNum: 3, Description: "slow test",
+				Directive: "SKIP not ready"}},
+		{"todo", "not ok 4 - unimplemented # TODO",
+			Line{Type: TestTodo, TestNum: 4, Description: "unimplemented", Directive: "TODO"}},
+		{"diagnostic", "# some comment", Line{Type: Diagnostic}},
+		{"bail", "Bail out! no more tests", Line{Type: Bail}},
+		{"unknown", "this is not TAP", Line{Type: Unknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseLine(tt.text))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := `TAP version 13
+1..2
+not ok 1 - subtraction works
+---
+message: 'failed'
+got: 3
+expected: 4
+...
+ok 2 - addition works
+`
+	var got []Line
+	err := Parse(strings.NewReader(input), func(text string, line Line) {
+		got = append(got, line)
+	})
+	assert.NoError(t, err)
+
+	want := []Line{
+		{Type: Version},
+		{Type: Plan, PlanFirst: 1, PlanLast: 2},
+		{Type: TestNOK, TestNum: 1, Description: "subtraction works",
+			YAML: &YAMLDiag{Message: "failed", Got: 3, Expected: 4}},
+		{Type: TestOK, OK: true, TestNum: 2, Description: "addition works"},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestParseYAMLDiag(t *testing.T) {
+	lines := []string{
+		"message: 'failed'",
+		"got: 3",
+		"expected: 4",
+	}
+	diag, err := ParseYAMLDiag(lines)
+	assert.NoError(t, err)
+	assert.Equal(t, "failed", diag.Message)
+	assert.Equal(t, 3, diag.Got)
+	assert.Equal(t, 4, diag.Expected)
+}